@@ -0,0 +1,91 @@
+package goque
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec defines how a Queue encodes and decodes the values passed to
+// EnqueueObject and DequeueObject, so that callers are not limited to
+// the built-in gob and JSON encodings.
+type Codec interface {
+	// Marshal encodes v into a byte slice suitable for Enqueue.
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes data, as returned by Marshal, into v. v
+	// should be a pointer to the variable where the decoded value
+	// will be stored.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// GobCodec encodes and decodes values using encoding/gob.
+//
+// Objects containing pointers with zero values will decode to nil
+// when using this codec. This is due to how the encoding/gob package
+// works. Because of this, GobCodec should only be used to encode
+// simple types.
+type GobCodec struct{}
+
+// Marshal encodes v using encoding/gob.
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Unmarshal decodes data using encoding/gob into v.
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// JSONCodec encodes and decodes values using encoding/json. Use this
+// codec to handle encoding of complex types.
+type JSONCodec struct{}
+
+// Marshal encodes v using encoding/json.
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes data using encoding/json into v.
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// ProtoCodec encodes and decodes values that implement
+// proto.Message using protocol buffers.
+type ProtoCodec struct{}
+
+// ErrNotProtoMessage is returned by ProtoCodec when the value passed
+// to Marshal or Unmarshal does not implement proto.Message.
+var ErrNotProtoMessage = errors.New("goque: Value does not implement proto.Message")
+
+// Marshal encodes v using protocol buffers. v must implement
+// proto.Message.
+func (ProtoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+
+	return proto.Marshal(m)
+}
+
+// Unmarshal decodes data using protocol buffers into v. v must
+// implement proto.Message.
+func (ProtoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+
+	return proto.Unmarshal(data, m)
+}
@@ -0,0 +1,50 @@
+package goque
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestEnqueueObjectDequeueObjectRoundTripWithCodec(t *testing.T) {
+	dataDir, err := os.MkdirTemp("", "goque_codec_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	q, err := OpenQueueWithCodec(dataDir, JSONCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	type payload struct {
+		Name string
+	}
+
+	if _, err := q.EnqueueObject(payload{Name: "widget"}); err != nil {
+		t.Fatalf("EnqueueObject: %v", err)
+	}
+
+	var got payload
+	if _, err := q.DequeueObject(0, &got); err != nil {
+		t.Fatalf("DequeueObject: %v", err)
+	}
+	if got.Name != "widget" {
+		t.Fatalf("got %+v; want Name=widget", got)
+	}
+}
+
+func TestProtoCodecRejectsNonProtoMessage(t *testing.T) {
+	var codec ProtoCodec
+
+	if _, err := codec.Marshal("not a proto message"); !errors.Is(err, ErrNotProtoMessage) {
+		t.Fatalf("Marshal error = %v; want ErrNotProtoMessage", err)
+	}
+
+	var dst string
+	if err := codec.Unmarshal([]byte("data"), &dst); !errors.Is(err, ErrNotProtoMessage) {
+		t.Fatalf("Unmarshal error = %v; want ErrNotProtoMessage", err)
+	}
+}
@@ -0,0 +1,221 @@
+package goque
+
+import (
+	"errors"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// ErrUnknownConsumer is returned when DequeueForConsumer or Commit is
+// called with a ConsumerID that was never returned by
+// RegisterConsumer on this queue.
+var ErrUnknownConsumer = errors.New("goque: Unknown consumer")
+
+// consumerKeyPrefix is the reserved key under which a consumer's
+// committed offset is stored.
+var consumerKeyPrefix = []byte{reservedKeyPrefix, 'c'}
+
+// ConsumerID identifies a named, persistent consumer group
+// registered against a Queue via RegisterConsumer.
+type ConsumerID string
+
+// consumerState is the in-memory bookkeeping kept for a registered
+// consumer. position is the next ID DequeueForConsumer will hand
+// out; committed is the last offset passed to Commit.
+type consumerState struct {
+	position  uint64
+	committed uint64
+}
+
+// RegisterConsumer registers a named, persistent consumer group on
+// the queue, returning its ConsumerID. Registering a name again after
+// the queue is reopened resumes from that consumer's last committed
+// offset rather than the beginning of the queue. Registering a name
+// that is already registered on this open Queue returns the same
+// ConsumerID and leaves its current read position unchanged.
+func (q *Queue) RegisterConsumer(name string) (ConsumerID, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	if !q.isOpen {
+		return "", ErrDBClosed
+	}
+
+	id := ConsumerID(name)
+
+	if _, ok := q.consumers[id]; ok {
+		return id, nil
+	}
+
+	offset := q.headInit
+
+	has, err := q.db.Has(consumerKey(id), nil)
+	if err != nil {
+		return "", err
+	}
+
+	if has {
+		committed, err := q.db.Get(consumerKey(id), nil)
+		if err != nil {
+			return "", err
+		}
+		offset = keyToID(committed)
+	} else if err := q.db.Put(consumerKey(id), idToKey(offset), q.wo); err != nil {
+		return "", err
+	}
+
+	q.consumers[id] = &consumerState{position: offset, committed: offset}
+
+	return id, nil
+}
+
+// DequeueForConsumer returns the next item for the given consumer,
+// advancing its in-memory read position. It does not delete the
+// item; call Commit once the item has been processed so the
+// background compactor can eventually reclaim it.
+func (q *Queue) DequeueForConsumer(consumer ConsumerID) (*Item, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	if !q.isOpen {
+		return nil, ErrDBClosed
+	}
+
+	state, ok := q.consumers[consumer]
+	if !ok {
+		return nil, ErrUnknownConsumer
+	}
+
+	item, err := q.getItemByID(state.position + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	state.position++
+
+	return item, nil
+}
+
+// Commit persists the given ID as the consumer's committed offset.
+// Items with an ID at or below the minimum committed offset across
+// all registered consumers become eligible for deletion by the
+// background compactor.
+func (q *Queue) Commit(consumer ConsumerID, id uint64) error {
+	q.Lock()
+	defer q.Unlock()
+
+	if !q.isOpen {
+		return ErrDBClosed
+	}
+
+	state, ok := q.consumers[consumer]
+	if !ok {
+		return ErrUnknownConsumer
+	}
+
+	if err := q.db.Put(consumerKey(consumer), idToKey(id), q.wo); err != nil {
+		return err
+	}
+
+	state.committed = id
+
+	return nil
+}
+
+// StartCompactor launches a background goroutine that periodically
+// deletes items whose ID is at or below the minimum offset across
+// every registered consumer and every legacy head index
+// (Dequeue/DequeueBatch/DequeueWait) that has already been called at
+// least once, even if that call found nothing, bounding the on-disk
+// size of the queue. Calling the
+// returned function stops the goroutine.
+//
+// A legacy head index that has never yet called Dequeue on this
+// Queue is invisible to the compactor, since it has no persisted
+// position the way a registered consumer does: if compact runs
+// before that index's first Dequeue, it may silently skip past items
+// that index would otherwise have read. Prefer RegisterConsumer for
+// every reader on a Queue that uses StartCompactor; only mix in the
+// legacy API if every index you intend to use has already read at
+// least once before compaction begins.
+func (q *Queue) StartCompactor(interval time.Duration) func() {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				_ = q.compact()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// compact deletes items whose ID is at or below the minimum offset
+// across all registered consumers and all legacy head indexes that
+// have already been called at least once (see the warning on
+// StartCompactor).
+func (q *Queue) compact() error {
+	q.Lock()
+	defer q.Unlock()
+
+	if !q.isOpen || len(q.consumers) == 0 {
+		return nil
+	}
+
+	min := q.consumers[firstConsumer(q.consumers)].committed
+	for _, state := range q.consumers {
+		if state.committed < min {
+			min = state.committed
+		}
+	}
+
+	// Don't compact past a legacy head that is still behind the
+	// consumers, so an index-based reader already in progress isn't
+	// cut off.
+	for _, head := range q.heads {
+		if head < min {
+			min = head
+		}
+	}
+
+	if min <= q.headInit {
+		return nil
+	}
+
+	batch := new(leveldb.Batch)
+	for id := q.headInit + 1; id <= min; id++ {
+		batch.Delete(idToKey(id))
+	}
+
+	if err := q.db.Write(batch, q.wo); err != nil {
+		return err
+	}
+
+	q.headInit = min
+
+	return nil
+}
+
+// firstConsumer returns an arbitrary key from the given map, used to
+// seed a minimum search over a non-empty map.
+func firstConsumer(consumers map[ConsumerID]*consumerState) ConsumerID {
+	for id := range consumers {
+		return id
+	}
+	return ""
+}
+
+// consumerKey builds the reserved key under which a consumer's
+// committed offset is stored.
+func consumerKey(id ConsumerID) []byte {
+	return append(append([]byte{}, consumerKeyPrefix...), []byte(id)...)
+}
@@ -0,0 +1,56 @@
+package goque
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCompactRespectsLegacyHead(t *testing.T) {
+	dataDir, err := os.MkdirTemp("", "goque_compact_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	q, err := OpenQueue(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	for i := 0; i < 5; i++ {
+		if _, err := q.EnqueueString("item"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Head 0 has only read the first two items.
+	if _, err := q.Dequeue(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Dequeue(0); err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := q.RegisterConsumer("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Commit(consumer, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Head 0 should still be able to read item 3, which it hasn't
+	// consumed yet, rather than having it deleted out from under it.
+	item, err := q.Dequeue(0)
+	if err != nil {
+		t.Fatalf("Dequeue(0) after compact = %v; want item 3, nil", err)
+	}
+	if item.ID != 3 {
+		t.Fatalf("Dequeue(0) after compact returned item %d; want 3", item.ID)
+	}
+}
@@ -0,0 +1,105 @@
+package goque
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Queue types used to tag a data directory so that it cannot later
+// be opened as an incompatible type. Each is a distinct bit so that
+// a data directory can be tagged with more than one of the
+// shareableGoqueTypes at once.
+const (
+	goqueQueue = 1 << iota
+	goquePriorityQueue
+	goqueStack
+	goqueUniqueQueue
+	goqueSet
+)
+
+// shareableGoqueTypes is the set of types that may coexist in the
+// same data directory, via OpenUniqueQueueFromDB / OpenSetFromDB on a
+// handle returned by OpenSharedDB. UniqueQueue and Set both key their
+// entries under disjoint, explicitly prefixed key ranges (see
+// unique_queue.go and set.go) precisely so several of them can share
+// one LevelDB. Queue keys its items directly off of idToKey with no
+// prefix and so must have a data directory to itself.
+const shareableGoqueTypes = goqueUniqueQueue | goqueSet
+
+// goqueTypeFile is the name of the file, stored alongside the
+// LevelDB files in a data directory, that records which Goque
+// type(s) originally created the data directory.
+const goqueTypeFile = "GOQUE"
+
+var (
+	// ErrEmpty is returned when the queue or stack is empty.
+	ErrEmpty = errors.New("goque: Queue or stack is empty")
+
+	// ErrOutOfBounds is returned when the index is out of bounds of
+	// the queue or stack.
+	ErrOutOfBounds = errors.New("goque: Index out of bounds")
+
+	// ErrDBClosed is returned when the database is closed.
+	ErrDBClosed = errors.New("goque: Database is closed")
+
+	// ErrIncompatibleType is returned when the given data directory
+	// was created by a different Goque type than the one being
+	// opened.
+	ErrIncompatibleType = errors.New("goque: Data directory contains an incompatible Goque type")
+)
+
+// checkGoqueType checks if the given data directory was already
+// tagged with a Goque type. If the data directory is new, it is
+// tagged with the given type. If the directory was already tagged
+// with one or more of shareableGoqueTypes and t is also one of them,
+// the directory is tagged with both and the open succeeds. It
+// returns false if the data directory was tagged with a different,
+// non-shareable type.
+func checkGoqueType(dataDir string, t int) (bool, error) {
+	f, err := os.OpenFile(filepath.Join(dataDir, goqueTypeFile), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	b := make([]byte, 1)
+	if _, err := f.Read(b); err != nil {
+		if err != io.EOF {
+			return false, err
+		}
+
+		// File is empty, so tag it with the requested type.
+		if _, err := f.WriteAt([]byte{byte(t)}, 0); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	cur := int(b[0])
+	if cur&t == t {
+		return true, nil
+	}
+
+	if cur&shareableGoqueTypes == cur && t&shareableGoqueTypes == t {
+		if _, err := f.WriteAt([]byte{byte(cur | t)}, 0); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// OpenSharedDB opens the LevelDB store at dataDir without tagging it
+// with any particular Goque type, so that the returned handle can be
+// passed to more than one of OpenUniqueQueueFromDB / OpenSetFromDB to
+// multiplex several shareable structures over a single LevelDB. The
+// caller owns the returned *leveldb.DB and must close it itself once
+// every structure built on top of it has been closed.
+func OpenSharedDB(dataDir string) (*leveldb.DB, error) {
+	return leveldb.OpenFile(dataDir, nil)
+}
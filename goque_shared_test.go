@@ -0,0 +1,72 @@
+package goque
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSharedDBUniqueQueueAndSet(t *testing.T) {
+	dataDir, err := os.MkdirTemp("", "goque_shared_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	db, err := OpenSharedDB(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	uq, err := OpenUniqueQueueFromDB(dataDir, db)
+	if err != nil {
+		t.Fatalf("OpenUniqueQueueFromDB: %v", err)
+	}
+	defer uq.Close()
+
+	s, err := OpenSetFromDB(dataDir, db)
+	if err != nil {
+		t.Fatalf("OpenSetFromDB: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := uq.EnqueueString("a"); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if err := s.Add([]byte("a")); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	has, err := s.Has([]byte("a"))
+	if err != nil || !has {
+		t.Fatalf("Has(a) = %v, %v; want true, nil", has, err)
+	}
+
+	item, err := uq.Dequeue(0)
+	if err != nil || item.ToString() != "a" {
+		t.Fatalf("Dequeue = %v, %v; want \"a\", nil", item, err)
+	}
+}
+
+func TestSetFromDBDropUnsupported(t *testing.T) {
+	dataDir, err := os.MkdirTemp("", "goque_shared_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	db, err := OpenSharedDB(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s, err := OpenSetFromDB(dataDir, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Drop(); err == nil {
+		t.Fatal("Drop on a Set opened from a shared database should fail")
+	}
+}
@@ -0,0 +1,48 @@
+package goque
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Item represents an entry in a queue, stack, or related structure.
+type Item struct {
+	ID    uint64
+	Key   []byte
+	Value []byte
+}
+
+// ToString returns the item value as a string.
+func (i *Item) ToString() string {
+	return string(i.Value)
+}
+
+// ToObject decodes the item value into the given variable using
+// encoding/gob. The value passed into this method should be a
+// pointer to the variable where the decoded value will be stored.
+func (i *Item) ToObject(value interface{}) error {
+	buffer := bytes.NewBuffer(i.Value)
+	dec := gob.NewDecoder(buffer)
+	return dec.Decode(value)
+}
+
+// ToObjectFromJSON decodes the item value into the given variable
+// using encoding/json. The value passed into this method should be a
+// pointer to the variable where the decoded value will be stored.
+func (i *Item) ToObjectFromJSON(value interface{}) error {
+	return json.Unmarshal(i.Value, value)
+}
+
+// idToKey converts an ID to a key.
+func idToKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// keyToID converts a key to an ID.
+func keyToID(key []byte) uint64 {
+	return binary.BigEndian.Uint64(key)
+}
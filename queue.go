@@ -1,45 +1,98 @@
 package goque
 
 import (
-	"bytes"
-	"encoding/gob"
-	"encoding/json"
+	"context"
+	"log"
 	"os"
 	"sync"
 
 	"github.com/syndtr/goleveldb/leveldb"
+	leveldberrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
+// reservedKeyPrefix marks the start of the reserved key range used
+// for Queue metadata, such as persistent consumer offsets, that is
+// stored alongside a queue's items but must never be mistaken for
+// one by init's head/tail scan.
+const reservedKeyPrefix = 0xFE
+
 // Queue is a standard FIFO (first in, first out) queue.
 type Queue struct {
 	sync.RWMutex
-	DataDir  string
-	db       *leveldb.DB
-	headInit uint64
-	heads    []uint64
-	tail     uint64
-	isOpen   bool
+	DataDir   string
+	db        *leveldb.DB
+	wo        *opt.WriteOptions
+	headInit  uint64
+	heads     []uint64
+	tail      uint64
+	isOpen    bool
+	consumers map[ConsumerID]*consumerState
+	codec     Codec
+	cond      *sync.Cond
+}
+
+// Options holds tunables for how a Queue interacts with its
+// underlying LevelDB store.
+type Options struct {
+	// WriteSync forces every write to be flushed to disk before
+	// returning, trading throughput for durability. It defaults to
+	// false, matching goleveldb's own default.
+	WriteSync bool
+
+	// Recover controls whether a corrupted LevelDB store is
+	// automatically repaired via leveldb.RecoverFile on open. OpenQueue
+	// enables it by passing nil Options; callers using
+	// OpenQueueWithOptions directly must set it explicitly, otherwise
+	// a corrupted store surfaces its open error as usual.
+	Recover bool
 }
 
 // OpenQueue opens a queue if one exists at the given directory. If one
 // does not already exist, a new queue is created.
 func OpenQueue(dataDir string) (*Queue, error) {
+	return OpenQueueWithOptions(dataDir, nil)
+}
+
+// OpenQueueWithOptions opens a queue the same way as OpenQueue, but
+// allows tuning its LevelDB write behavior via opts. A nil opts
+// behaves identically to OpenQueue.
+func OpenQueueWithOptions(dataDir string, opts *Options) (*Queue, error) {
 	var err error
 
 	// Create a new Queue.
 	q := &Queue{
-		DataDir:  dataDir,
-		db:       &leveldb.DB{},
-		headInit: 0,
-		heads:    []uint64{},
-		tail:     0,
-		isOpen:   false,
+		DataDir:   dataDir,
+		db:        &leveldb.DB{},
+		headInit:  0,
+		heads:     []uint64{},
+		tail:      0,
+		isOpen:    false,
+		consumers: make(map[ConsumerID]*consumerState),
+	}
+	q.cond = sync.NewCond(&q.RWMutex)
+
+	autoRecover := opts == nil
+	if opts != nil {
+		q.wo = &opt.WriteOptions{Sync: opts.WriteSync}
+		autoRecover = opts.Recover
 	}
 
 	// Open database for the queue.
 	q.db, err = leveldb.OpenFile(dataDir, nil)
 	if err != nil {
-		return q, err
+		if !autoRecover || !leveldberrors.IsCorrupted(err) {
+			return q, err
+		}
+
+		recoveredDB, rerr := leveldb.RecoverFile(dataDir, nil)
+		if rerr != nil {
+			return q, rerr
+		}
+
+		log.Printf("goque: recovered %d records from corrupted database at %s", countKeys(recoveredDB), dataDir)
+		q.db = recoveredDB
 	}
 
 	// Check if this Goque type can open the requested data directory.
@@ -56,6 +109,20 @@ func OpenQueue(dataDir string) (*Queue, error) {
 	return q, q.init()
 }
 
+// OpenQueueWithCodec opens a queue the same way as OpenQueue, but
+// attaches codec so that EnqueueObject and DequeueObject can be used
+// with encodings other than the built-in gob and JSON helpers.
+func OpenQueueWithCodec(dataDir string, codec Codec) (*Queue, error) {
+	q, err := OpenQueueWithOptions(dataDir, nil)
+	if err != nil {
+		return q, err
+	}
+
+	q.codec = codec
+
+	return q, nil
+}
+
 // Enqueue adds an item to the queue.
 func (q *Queue) Enqueue(value []byte) (*Item, error) {
 	q.Lock()
@@ -74,16 +141,56 @@ func (q *Queue) Enqueue(value []byte) (*Item, error) {
 	}
 
 	// Add it to the queue.
-	if err := q.db.Put(item.Key, item.Value, nil); err != nil {
+	if err := q.db.Put(item.Key, item.Value, q.wo); err != nil {
 		return nil, err
 	}
 
 	// Increment tail position.
 	q.tail++
 
+	// Wake any goroutines blocked in DequeueWait.
+	q.cond.Broadcast()
+
 	return item, nil
 }
 
+// EnqueueBatch adds multiple items to the queue as a single
+// leveldb.Batch, incurring one WAL sync for the whole batch instead
+// of one per item. Items are assigned contiguous IDs in the order
+// given.
+func (q *Queue) EnqueueBatch(values [][]byte) ([]*Item, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	// Check if queue is closed.
+	if !q.isOpen {
+		return nil, ErrDBClosed
+	}
+
+	items := make([]*Item, len(values))
+	batch := new(leveldb.Batch)
+
+	id := q.tail
+	for i, value := range values {
+		id++
+		item := &Item{ID: id, Key: idToKey(id), Value: value}
+		batch.Put(item.Key, item.Value)
+		items[i] = item
+	}
+
+	if err := q.db.Write(batch, q.wo); err != nil {
+		return nil, err
+	}
+
+	// Advance tail position once for the whole batch.
+	q.tail = id
+
+	// Wake any goroutines blocked in DequeueWait.
+	q.cond.Broadcast()
+
+	return items, nil
+}
+
 // EnqueueString is a helper function for Enqueue that accepts a
 // value as a string rather than a byte slice.
 func (q *Queue) EnqueueString(value string) (*Item, error) {
@@ -91,38 +198,51 @@ func (q *Queue) EnqueueString(value string) (*Item, error) {
 }
 
 // EnqueueObject is a helper function for Enqueue that accepts any
-// value type, which is then encoded into a byte slice using
-// encoding/gob.
+// value type, which is then encoded into a byte slice using the
+// queue's codec, as set by OpenQueueWithCodec, or GobCodec if none
+// was set. Pair it with DequeueObject, which decodes using the same
+// codec.
 //
 // Objects containing pointers with zero values will decode to nil
-// when using this function. This is due to how the encoding/gob
-// package works. Because of this, you should only use this function
-// to encode simple types.
+// when using GobCodec. This is due to how the encoding/gob package
+// works. Because of this, GobCodec should only be used to encode
+// simple types.
 func (q *Queue) EnqueueObject(value interface{}) (*Item, error) {
-	var buffer bytes.Buffer
-	enc := gob.NewEncoder(&buffer)
-	if err := enc.Encode(value); err != nil {
+	data, err := q.codecOrDefault().Marshal(value)
+	if err != nil {
 		return nil, err
 	}
 
-	return q.Enqueue(buffer.Bytes())
+	return q.Enqueue(data)
 }
 
 // EnqueueObjectAsJSON is a helper function for Enqueue that accepts
-// any value type, which is then encoded into a JSON byte slice using
-// encoding/json.
-//
-// Use this function to handle encoding of complex types.
+// any value type, which is then encoded using JSONCodec regardless of
+// the queue's codec. It is kept as a separate name for backward
+// compatibility with code written before OpenQueueWithCodec existed;
+// new code should call OpenQueueWithCodec(dataDir, JSONCodec{}) and
+// use EnqueueObject instead.
 func (q *Queue) EnqueueObjectAsJSON(value interface{}) (*Item, error) {
-	jsonBytes, err := json.Marshal(value)
+	data, err := JSONCodec{}.Marshal(value)
 	if err != nil {
 		return nil, err
 	}
 
-	return q.Enqueue(jsonBytes)
+	return q.Enqueue(data)
+}
+
+// codecOrDefault returns the queue's configured codec, as set by
+// OpenQueueWithCodec, or GobCodec if none was set.
+func (q *Queue) codecOrDefault() Codec {
+	if q.codec != nil {
+		return q.codec
+	}
+
+	return GobCodec{}
 }
 
-// Dequeue removes the next item in the queue and returns it.
+// Dequeue removes the next item in the queue and returns it. It
+// returns ErrEmpty if no item is available at head i.
 func (q *Queue) Dequeue(i int) (*Item, error) {
 	q.Lock()
 	defer q.Unlock()
@@ -148,6 +268,121 @@ func (q *Queue) Dequeue(i int) (*Item, error) {
 	return item, nil
 }
 
+// DequeueWait blocks until an item is available at head i or ctx is
+// done, instead of returning ErrEmpty immediately like Dequeue.
+func (q *Queue) DequeueWait(ctx context.Context, i int) (*Item, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	// Check if queue is closed.
+	if !q.isOpen {
+		return nil, ErrDBClosed
+	}
+
+	if len(q.heads) < i+1 {
+		q.heads = append(q.heads, q.headInit)
+	}
+
+	// Wake this goroutine's Wait if ctx is done before an item
+	// becomes available. The broadcast is serialized with q's lock
+	// so it can't land between the loop's ctx.Err() check and the
+	// Wait() call below, where it would otherwise be lost and leave
+	// this goroutine blocked past ctx's deadline.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.Lock()
+			q.cond.Broadcast()
+			q.Unlock()
+		case <-done:
+		}
+	}()
+
+	for q.isOpen && q.heads[i] == q.tail && ctx.Err() == nil {
+		q.cond.Wait()
+	}
+
+	if !q.isOpen {
+		return nil, ErrDBClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Try to get the next item in the queue.
+	item, err := q.getItemByID(q.heads[i] + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Increment head position.
+	q.heads[i]++
+
+	return item, nil
+}
+
+// DequeueObject is a helper function for Dequeue that decodes the
+// dequeued item's value into v using the queue's codec, as set by
+// OpenQueueWithCodec, or GobCodec if none was set.
+func (q *Queue) DequeueObject(i int, v interface{}) (*Item, error) {
+	item, err := q.Dequeue(i)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.codecOrDefault().Unmarshal(item.Value, v); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// DequeueBatch returns up to n items starting at head i, advancing
+// the head position by the number of items actually returned. If
+// fewer than n items are available, the items found so far are
+// returned without error; if none are available, it returns ErrEmpty
+// like Dequeue. A non-positive n returns nil, nil.
+func (q *Queue) DequeueBatch(i int, n int) ([]*Item, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	// Check if queue is closed.
+	if !q.isOpen {
+		return nil, ErrDBClosed
+	}
+
+	if n <= 0 {
+		return nil, nil
+	}
+
+	if len(q.heads) < i+1 {
+		q.heads = append(q.heads, q.headInit)
+	}
+
+	items := make([]*Item, 0, n)
+	head := q.heads[i]
+
+	for len(items) < n {
+		item, err := q.getItemByID(head + 1)
+		if err != nil {
+			if len(items) > 0 {
+				break
+			}
+			return nil, err
+		}
+
+		items = append(items, item)
+		head++
+	}
+
+	// Advance head position once for the whole batch.
+	q.heads[i] = head
+
+	return items, nil
+}
+
 // Close closes the LevelDB database of the queue.
 func (q *Queue) Close() error {
 	q.Lock()
@@ -169,6 +404,11 @@ func (q *Queue) Close() error {
 	q.heads = []uint64{}
 	q.tail = 0
 	q.isOpen = false
+	q.consumers = make(map[ConsumerID]*consumerState)
+
+	// Wake any goroutines blocked in DequeueWait so they can return
+	// ErrDBClosed instead of hanging.
+	q.cond.Broadcast()
 
 	return nil
 }
@@ -182,22 +422,42 @@ func (q *Queue) Drop() error {
 	return os.RemoveAll(q.DataDir)
 }
 
-// getItemByID returns an item, if found, for the given ID.
+// getItemByID returns an item, if found, for the given ID. It
+// returns ErrEmpty if no item is stored at that ID.
 func (q *Queue) getItemByID(id uint64) (*Item, error) {
 	// Get item from database.
 	var err error
 	item := &Item{ID: id, Key: idToKey(id)}
 	if item.Value, err = q.db.Get(item.Key, nil); err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil, ErrEmpty
+		}
 		return nil, err
 	}
 
 	return item, nil
 }
 
+// countKeys returns the number of keys present in db, used to report
+// how many records survived a corruption recovery.
+func countKeys(db *leveldb.DB) int {
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	var count int
+	for iter.Next() {
+		count++
+	}
+
+	return count
+}
+
 // init initializes the queue data.
 func (q *Queue) init() error {
-	// Create a new LevelDB Iterator.
-	iter := q.db.NewIterator(nil, nil)
+	// Create a new LevelDB Iterator, bounded to the item key range so
+	// that reserved metadata keys (e.g. consumer offsets) are never
+	// mistaken for the first or last item.
+	iter := q.db.NewIterator(&util.Range{Limit: []byte{reservedKeyPrefix}}, nil)
 	defer iter.Release()
 
 	// Set queue head to the first item.
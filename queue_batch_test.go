@@ -0,0 +1,94 @@
+package goque
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEnqueueBatchDequeueBatch(t *testing.T) {
+	dataDir, err := os.MkdirTemp("", "goque_batch_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	q, err := OpenQueue(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	values := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	items, err := q.EnqueueBatch(values)
+	if err != nil {
+		t.Fatalf("EnqueueBatch: %v", err)
+	}
+	if len(items) != len(values) {
+		t.Fatalf("EnqueueBatch returned %d items; want %d", len(items), len(values))
+	}
+
+	got, err := q.DequeueBatch(0, 2)
+	if err != nil {
+		t.Fatalf("DequeueBatch: %v", err)
+	}
+	if len(got) != 2 || got[0].ToString() != "a" || got[1].ToString() != "b" {
+		t.Fatalf("DequeueBatch(0, 2) = %v; want [a b]", got)
+	}
+
+	// Fewer than n items remain: DequeueBatch should return what's
+	// there instead of erroring.
+	rest, err := q.DequeueBatch(0, 5)
+	if err != nil {
+		t.Fatalf("DequeueBatch with fewer than n remaining: %v", err)
+	}
+	if len(rest) != 1 || rest[0].ToString() != "c" {
+		t.Fatalf("DequeueBatch(0, 5) = %v; want [c]", rest)
+	}
+}
+
+func TestDequeueBatchNonPositiveN(t *testing.T) {
+	dataDir, err := os.MkdirTemp("", "goque_batch_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	q, err := OpenQueue(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	if _, err := q.EnqueueString("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{0, -1} {
+		items, err := q.DequeueBatch(0, n)
+		if err != nil || items != nil {
+			t.Fatalf("DequeueBatch(0, %d) = %v, %v; want nil, nil", n, items, err)
+		}
+	}
+}
+
+func TestOpenQueueWithOptionsWriteSync(t *testing.T) {
+	dataDir, err := os.MkdirTemp("", "goque_batch_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	q, err := OpenQueueWithOptions(dataDir, &Options{WriteSync: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	item, err := q.EnqueueString("synced")
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if item.ToString() != "synced" {
+		t.Fatalf("got %q; want \"synced\"", item.ToString())
+	}
+}
@@ -0,0 +1,40 @@
+package goque
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDequeueWaitReturnsOnContextCancel(t *testing.T) {
+	dataDir, err := os.MkdirTemp("", "goque_dequeue_wait_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	q, err := OpenQueue(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := q.DequeueWait(ctx, 0)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("DequeueWait error = %v; want context.DeadlineExceeded", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("DequeueWait did not return after its context expired; lost wakeup?")
+	}
+}
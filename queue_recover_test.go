@@ -0,0 +1,81 @@
+package goque
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenQueueRecoversFromCorruptManifest(t *testing.T) {
+	dataDir, err := os.MkdirTemp("", "goque_recover_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	q, err := OpenQueue(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := q.EnqueueString("item"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	corruptManifest(t, dataDir)
+
+	if _, err := OpenQueueWithOptions(dataDir, &Options{}); err == nil {
+		t.Fatal("OpenQueueWithOptions with Recover: false should surface the corruption error")
+	}
+
+	recovered, err := OpenQueue(dataDir)
+	if err != nil {
+		t.Fatalf("OpenQueue should auto-recover a corrupted store, got: %v", err)
+	}
+	defer recovered.Close()
+
+	item, err := recovered.Dequeue(0)
+	if err != nil {
+		t.Fatalf("Dequeue after recovery: %v", err)
+	}
+	if item.ToString() != "item" {
+		t.Fatalf("Dequeue after recovery = %q; want \"item\"", item.ToString())
+	}
+}
+
+// corruptManifest overwrites the start of the LevelDB MANIFEST file
+// in dataDir so that a subsequent OpenFile fails with a corruption
+// error, the same way a process crash mid-write can.
+func corruptManifest(t *testing.T, dataDir string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), "MANIFEST") {
+			continue
+		}
+
+		f, err := os.OpenFile(filepath.Join(dataDir, e.Name()), os.O_WRONLY, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteAt([]byte{0xDE, 0xAD, 0xBE, 0xEF, 0xFF, 0xFF, 0xFF, 0xFF}, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	t.Fatal("no MANIFEST file found to corrupt")
+}
@@ -0,0 +1,176 @@
+package goque
+
+import (
+	"crypto/sha256"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// setMemberPrefix scopes a Set's members to their own key range
+// within the LevelDB, keyed by the sha256 sum of the member value so
+// that a Set can share a database with a UniqueQueue opened via
+// OpenSetFromDB / OpenUniqueQueueFromDB on the same handle.
+var setMemberPrefix = []byte("set:")
+
+// Set is an unordered collection of unique byte slice values.
+type Set struct {
+	sync.RWMutex
+	DataDir string
+	db      *leveldb.DB
+	owned   bool
+	isOpen  bool
+}
+
+// OpenSet opens a set if one exists at the given directory. If one
+// does not already exist, a new set is created. The set opens and
+// owns its own LevelDB; use OpenSetFromDB to multiplex a Set onto a
+// database shared with a UniqueQueue.
+func OpenSet(dataDir string) (*Set, error) {
+	db, err := leveldb.OpenFile(dataDir, nil)
+	if err != nil {
+		return &Set{DataDir: dataDir, db: &leveldb.DB{}}, err
+	}
+
+	return newSet(dataDir, db, true)
+}
+
+// OpenSetFromDB opens a set backed by db, a LevelDB handle already
+// opened via OpenSharedDB, instead of opening its own. This is how a
+// Set and a UniqueQueue (see OpenUniqueQueueFromDB) can share a
+// single LevelDB, since both key their entries under disjoint
+// prefixes. The caller retains ownership of db; Close on the
+// returned Set does not close it.
+func OpenSetFromDB(dataDir string, db *leveldb.DB) (*Set, error) {
+	return newSet(dataDir, db, false)
+}
+
+func newSet(dataDir string, db *leveldb.DB, owned bool) (*Set, error) {
+	s := &Set{DataDir: dataDir, db: db, owned: owned}
+
+	ok, err := checkGoqueType(dataDir, goqueSet)
+	if err != nil {
+		return s, err
+	}
+	if !ok {
+		return s, ErrIncompatibleType
+	}
+
+	s.isOpen = true
+	return s, nil
+}
+
+// Add adds the given value to the set. It is a no-op if the value is
+// already a member of the set.
+func (s *Set) Add(value []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.isOpen {
+		return ErrDBClosed
+	}
+
+	return s.db.Put(setMemberKey(value), value, nil)
+}
+
+// Has reports whether the given value is a member of the set.
+func (s *Set) Has(value []byte) (bool, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if !s.isOpen {
+		return false, ErrDBClosed
+	}
+
+	return s.db.Has(setMemberKey(value), nil)
+}
+
+// Remove removes the given value from the set. It is a no-op if the
+// value is not a member of the set.
+func (s *Set) Remove(value []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.isOpen {
+		return ErrDBClosed
+	}
+
+	return s.db.Delete(setMemberKey(value), nil)
+}
+
+// Members returns every value currently in the set.
+func (s *Set) Members() ([][]byte, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if !s.isOpen {
+		return nil, ErrDBClosed
+	}
+
+	iter := s.db.NewIterator(util.BytesPrefix(setMemberPrefix), nil)
+	defer iter.Release()
+
+	var members [][]byte
+	for iter.Next() {
+		value := make([]byte, len(iter.Value()))
+		copy(value, iter.Value())
+		members = append(members, value)
+	}
+
+	return members, iter.Error()
+}
+
+// Close closes the set. If the set owns its LevelDB (opened via
+// OpenSet), the database is closed too; a set opened via
+// OpenSetFromDB leaves the shared database open for its other
+// structures.
+func (s *Set) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.isOpen {
+		return nil
+	}
+
+	if s.owned {
+		if err := s.db.Close(); err != nil {
+			return err
+		}
+	}
+
+	s.isOpen = false
+
+	return nil
+}
+
+// Drop closes and deletes the LevelDB database of the set. Drop
+// returns an error for a set opened via OpenSetFromDB, since removing
+// the data directory out from under a database handle that other
+// structures may still be using would corrupt them; close every
+// structure sharing the database first, then remove the directory
+// yourself.
+func (s *Set) Drop() error {
+	if !s.owned {
+		return errors.New("goque: Drop is not supported for a Set opened from a shared database")
+	}
+
+	if err := s.Close(); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(s.DataDir)
+}
+
+// setMemberKey builds the key under which a member value is stored,
+// keyed by its sha256 sum so that values of arbitrary length sort
+// into a fixed-width key range.
+func setMemberKey(value []byte) []byte {
+	sum := sha256.Sum256(value)
+	key := make([]byte, len(setMemberPrefix)+len(sum))
+	n := copy(key, setMemberPrefix)
+	copy(key[n:], sum[:])
+	return key
+}
@@ -0,0 +1,289 @@
+package goque
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// ErrAlreadyInQueue is returned when the value passed to Enqueue is
+// already waiting in the queue.
+var ErrAlreadyInQueue = errors.New("goque: Value already in the queue")
+
+// uniqueItemPrefix and uniqueIndexPrefix scope the queue's item keys
+// and its secondary duplicate-detection index to disjoint, ordered
+// key ranges within the same LevelDB, so that a UniqueQueue can share
+// a database with a Set opened via OpenSetFromDB on the same handle.
+var (
+	uniqueItemPrefix  = []byte("itm:")
+	uniqueIndexPrefix = []byte("idx:")
+)
+
+// UniqueQueue is a standard FIFO (first in, first out) queue that
+// additionally rejects Enqueue calls for values that are already
+// pending in the queue.
+type UniqueQueue struct {
+	sync.RWMutex
+	DataDir  string
+	db       *leveldb.DB
+	owned    bool
+	headInit uint64
+	heads    []uint64
+	tail     uint64
+	isOpen   bool
+}
+
+// OpenUniqueQueue opens a unique queue if one exists at the given
+// directory. If one does not already exist, a new unique queue is
+// created. The queue opens and owns its own LevelDB; use
+// OpenUniqueQueueFromDB to multiplex a UniqueQueue onto a database
+// shared with a Set.
+func OpenUniqueQueue(dataDir string) (*UniqueQueue, error) {
+	db, err := leveldb.OpenFile(dataDir, nil)
+	if err != nil {
+		return &UniqueQueue{DataDir: dataDir, db: &leveldb.DB{}}, err
+	}
+
+	return newUniqueQueue(dataDir, db, true)
+}
+
+// OpenUniqueQueueFromDB opens a unique queue backed by db, a LevelDB
+// handle already opened via OpenSharedDB, instead of opening its own.
+// This is how a UniqueQueue and a Set (see OpenSetFromDB) can share a
+// single LevelDB, since both key their entries under disjoint
+// prefixes. The caller retains ownership of db; Close on the
+// returned UniqueQueue does not close it.
+func OpenUniqueQueueFromDB(dataDir string, db *leveldb.DB) (*UniqueQueue, error) {
+	return newUniqueQueue(dataDir, db, false)
+}
+
+func newUniqueQueue(dataDir string, db *leveldb.DB, owned bool) (*UniqueQueue, error) {
+	q := &UniqueQueue{DataDir: dataDir, db: db, owned: owned}
+
+	ok, err := checkGoqueType(dataDir, goqueUniqueQueue)
+	if err != nil {
+		return q, err
+	}
+	if !ok {
+		return q, ErrIncompatibleType
+	}
+
+	q.isOpen = true
+	return q, q.init()
+}
+
+// Enqueue adds an item to the queue, unless a value equal to it is
+// already pending in the queue, in which case ErrAlreadyInQueue is
+// returned.
+func (q *UniqueQueue) Enqueue(value []byte) (*Item, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	if !q.isOpen {
+		return nil, ErrDBClosed
+	}
+
+	indexKey := uniqueIndexKey(value)
+
+	has, err := q.db.Has(indexKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	if has {
+		return nil, ErrAlreadyInQueue
+	}
+
+	item := &Item{
+		ID:    q.tail + 1,
+		Key:   uniqueItemKey(q.tail + 1),
+		Value: value,
+	}
+
+	// Write the item and its index entry atomically.
+	batch := new(leveldb.Batch)
+	batch.Put(item.Key, item.Value)
+	batch.Put(indexKey, item.Key)
+	if err := q.db.Write(batch, nil); err != nil {
+		return nil, err
+	}
+
+	q.tail++
+
+	return item, nil
+}
+
+// EnqueueString is a helper function for Enqueue that accepts a
+// value as a string rather than a byte slice.
+func (q *UniqueQueue) EnqueueString(value string) (*Item, error) {
+	return q.Enqueue([]byte(value))
+}
+
+// EnqueueObject is a helper function for Enqueue that accepts any
+// value type, which is then encoded into a byte slice using
+// encoding/gob.
+//
+// Objects containing pointers with zero values will decode to nil
+// when using this function. This is due to how the encoding/gob
+// package works. Because of this, you should only use this function
+// to encode simple types.
+func (q *UniqueQueue) EnqueueObject(value interface{}) (*Item, error) {
+	var buffer bytes.Buffer
+	enc := gob.NewEncoder(&buffer)
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return q.Enqueue(buffer.Bytes())
+}
+
+// EnqueueObjectAsJSON is a helper function for Enqueue that accepts
+// any value type, which is then encoded into a JSON byte slice using
+// encoding/json.
+//
+// Use this function to handle encoding of complex types.
+func (q *UniqueQueue) EnqueueObjectAsJSON(value interface{}) (*Item, error) {
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.Enqueue(jsonBytes)
+}
+
+// Dequeue removes the next item in the queue and returns it, also
+// removing its entry from the duplicate index.
+func (q *UniqueQueue) Dequeue(i int) (*Item, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	if !q.isOpen {
+		return nil, ErrDBClosed
+	}
+
+	if len(q.heads) < i+1 {
+		q.heads = append(q.heads, q.headInit)
+	}
+
+	item, err := q.getItemByID(q.heads[i] + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.db.Delete(uniqueIndexKey(item.Value), nil); err != nil {
+		return nil, err
+	}
+
+	q.heads[i]++
+
+	return item, nil
+}
+
+// Has reports whether a value equal to the given one is currently
+// pending in the queue.
+func (q *UniqueQueue) Has(value []byte) (bool, error) {
+	q.RLock()
+	defer q.RUnlock()
+
+	if !q.isOpen {
+		return false, ErrDBClosed
+	}
+
+	return q.db.Has(uniqueIndexKey(value), nil)
+}
+
+// Close closes the queue. If the queue owns its LevelDB (opened via
+// OpenUniqueQueue), the database is closed too; a queue opened via
+// OpenUniqueQueueFromDB leaves the shared database open for its
+// other structures.
+func (q *UniqueQueue) Close() error {
+	q.Lock()
+	defer q.Unlock()
+
+	if !q.isOpen {
+		return nil
+	}
+
+	if q.owned {
+		if err := q.db.Close(); err != nil {
+			return err
+		}
+	}
+
+	q.headInit = 0
+	q.heads = []uint64{}
+	q.tail = 0
+	q.isOpen = false
+
+	return nil
+}
+
+// Drop closes and deletes the LevelDB database of the queue. Drop
+// returns an error for a queue opened via OpenUniqueQueueFromDB,
+// since removing the data directory out from under a database handle
+// that other structures may still be using would corrupt them; close
+// every structure sharing the database first, then remove the
+// directory yourself.
+func (q *UniqueQueue) Drop() error {
+	if !q.owned {
+		return errors.New("goque: Drop is not supported for a UniqueQueue opened from a shared database")
+	}
+
+	if err := q.Close(); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(q.DataDir)
+}
+
+// getItemByID returns an item, if found, for the given ID. It
+// returns ErrEmpty if no item is stored at that ID.
+func (q *UniqueQueue) getItemByID(id uint64) (*Item, error) {
+	var err error
+	item := &Item{ID: id, Key: uniqueItemKey(id)}
+	if item.Value, err = q.db.Get(item.Key, nil); err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil, ErrEmpty
+		}
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// init initializes the queue data.
+func (q *UniqueQueue) init() error {
+	iter := q.db.NewIterator(util.BytesPrefix(uniqueItemPrefix), nil)
+	defer iter.Release()
+
+	if iter.First() {
+		q.headInit = keyToID(iter.Key()[len(uniqueItemPrefix):]) - 1
+	}
+
+	if iter.Last() {
+		q.tail = keyToID(iter.Key()[len(uniqueItemPrefix):])
+	}
+
+	return iter.Error()
+}
+
+// uniqueItemKey builds the key under which an item is stored.
+func uniqueItemKey(id uint64) []byte {
+	return append(append([]byte{}, uniqueItemPrefix...), idToKey(id)...)
+}
+
+// uniqueIndexKey builds the secondary index key used to detect a
+// duplicate value.
+func uniqueIndexKey(value []byte) []byte {
+	sum := sha256.Sum256(value)
+	key := make([]byte, len(uniqueIndexPrefix)+len(sum))
+	n := copy(key, uniqueIndexPrefix)
+	copy(key[n:], sum[:])
+	return key
+}
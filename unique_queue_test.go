@@ -0,0 +1,45 @@
+package goque
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestUniqueQueueRejectsDuplicateValues(t *testing.T) {
+	dataDir, err := os.MkdirTemp("", "goque_unique_queue_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	q, err := OpenUniqueQueue(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	if _, err := q.EnqueueString("a"); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	if _, err := q.EnqueueString("a"); !errors.Is(err, ErrAlreadyInQueue) {
+		t.Fatalf("duplicate Enqueue error = %v; want ErrAlreadyInQueue", err)
+	}
+
+	has, err := q.Has([]byte("a"))
+	if err != nil || !has {
+		t.Fatalf("Has(a) = %v, %v; want true, nil", has, err)
+	}
+
+	item, err := q.Dequeue(0)
+	if err != nil || item.ToString() != "a" {
+		t.Fatalf("Dequeue = %v, %v; want \"a\", nil", item, err)
+	}
+
+	// Once dequeued, the value is no longer pending and can be
+	// enqueued again.
+	if _, err := q.EnqueueString("a"); err != nil {
+		t.Fatalf("re-Enqueue after Dequeue: %v", err)
+	}
+}